@@ -0,0 +1,244 @@
+package alerting
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	"statusok/notify"
+)
+
+//Outcome values recorded on a Sample, mirroring the result labels used by the metrics package
+const (
+	OutcomeSuccess        = "success"
+	OutcomeCodeMismatch   = "code_mismatch"
+	OutcomeBodyInvalid    = "body_invalid"
+	OutcomeTransportError = "transport_error"
+)
+
+//DefaultRingSize is how many samples are kept per request ID
+const DefaultRingSize = 200
+
+//State a Rule can be in
+type State string
+
+const (
+	StateInactive State = "inactive"
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+)
+
+//Sample is one observed outcome for a monitored request
+type Sample struct {
+	Time    time.Time
+	Outcome string
+	Latency time.Duration
+}
+
+//Condition is evaluated against the samples collected for a Rule's RequestIds
+type Condition func(samples []Sample) bool
+
+//Rule is a single alerting rule, evaluated every time one of its RequestIds gets a new sample.
+//Expression is a config-friendly condition such as "consecutive_failures >= 3",
+//"p95_response_time_ms > 500 for 5m", "body_invalid for 2m", or
+//"code != 200 ratio > 0.1 over 10m" - see ParseExpression for the supported grammar.
+//Condition is the equivalent Go-level escape hatch for rules built in code (eg. in tests);
+//if both are set, Condition takes precedence.
+type Rule struct {
+	Name        string        `json:"name"`
+	RequestIds  []int         `json:"requestIds"`
+	Expression  string        `json:"expression,omitempty"`
+	Condition   Condition     `json:"-"`
+	For         time.Duration `json:"for"`
+	Severity    string        `json:"severity"`
+	Summary     string        `json:"summary"`
+	Description string        `json:"description"` //text/template, executed against templateData
+
+	mu           sync.Mutex
+	state        State
+	becameTrueAt time.Time
+
+	resolveOnce sync.Once
+	resolved    Condition
+	resolveErr  error
+}
+
+//condition returns the Condition this rule evaluates against, compiling Expression the
+//first time it's needed. The result is cached, so a bad Expression is reported once.
+func (rule *Rule) condition() (Condition, error) {
+	rule.resolveOnce.Do(func() {
+		if rule.Condition != nil {
+			rule.resolved = rule.Condition
+			return
+		}
+
+		rule.resolved, rule.resolveErr = ParseExpression(rule.Expression)
+	})
+
+	return rule.resolved, rule.resolveErr
+}
+
+func (rule *Rule) references(requestId int) bool {
+	for _, id := range rule.RequestIds {
+		if id == requestId {
+			return true
+		}
+	}
+
+	return false
+}
+
+//ringBuffer keeps the most recent `size` samples for one request ID
+type ringBuffer struct {
+	mu      sync.Mutex
+	samples []Sample
+	size    int
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size}
+}
+
+func (r *ringBuffer) add(sample Sample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.samples = append(r.samples, sample)
+	if len(r.samples) > r.size {
+		r.samples = r.samples[len(r.samples)-r.size:]
+	}
+}
+
+func (r *ringBuffer) snapshot() []Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]Sample, len(r.samples))
+	copy(out, r.samples)
+	return out
+}
+
+//Evaluator holds per-request sample history and re-evaluates affected rules as samples come in
+type Evaluator struct {
+	mu       sync.Mutex
+	buffers  map[int]*ringBuffer
+	rules    []*Rule
+	ringSize int
+}
+
+//NewEvaluator creates an Evaluator for the given rule set
+func NewEvaluator(rules []*Rule) *Evaluator {
+	return &Evaluator{
+		buffers:  make(map[int]*ringBuffer),
+		rules:    rules,
+		ringSize: DefaultRingSize,
+	}
+}
+
+//Record appends a sample for requestId, then re-evaluates every rule that references it
+func (e *Evaluator) Record(requestId int, sample Sample) {
+	e.mu.Lock()
+	buf, ok := e.buffers[requestId]
+	if !ok {
+		buf = newRingBuffer(e.ringSize)
+		e.buffers[requestId] = buf
+	}
+	e.mu.Unlock()
+
+	buf.add(sample)
+
+	for _, rule := range e.rules {
+		if rule.references(requestId) {
+			e.evaluate(rule)
+		}
+	}
+}
+
+func (e *Evaluator) samplesFor(requestIds []int) []Sample {
+	var all []Sample
+
+	e.mu.Lock()
+	for _, id := range requestIds {
+		if buf, ok := e.buffers[id]; ok {
+			all = append(all, buf.snapshot()...)
+		}
+	}
+	e.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Time.Before(all[j].Time) })
+
+	return all
+}
+
+//evaluate re-checks a rule's Condition and drives its inactive -> pending -> firing state machine
+func (e *Evaluator) evaluate(rule *Rule) {
+	cond, err := rule.condition()
+	if err != nil {
+		println("\nalerting: rule", rule.Name, "has an invalid expression:", err.Error())
+		return
+	}
+
+	samples := e.samplesFor(rule.RequestIds)
+	matched := cond(samples)
+
+	rule.mu.Lock()
+	defer rule.mu.Unlock()
+
+	if !matched {
+		wasFiring := rule.state == StateFiring
+		rule.state = StateInactive
+		rule.becameTrueAt = time.Time{}
+
+		if wasFiring {
+			e.notify(rule, samples, false)
+		}
+		return
+	}
+
+	if rule.becameTrueAt.IsZero() {
+		rule.becameTrueAt = time.Now()
+	}
+
+	if rule.state != StateFiring && time.Since(rule.becameTrueAt) >= rule.For {
+		rule.state = StateFiring
+		e.notify(rule, samples, true)
+		return
+	}
+
+	if rule.state != StateFiring {
+		rule.state = StatePending
+	}
+}
+
+//templateData is made available to Rule.Description when rendered as a text/template
+type templateData struct {
+	Rule    *Rule
+	Samples []Sample
+	Firing  bool
+	Time    time.Time
+}
+
+//notify renders the rule's description and sends a firing or resolved notification
+func (e *Evaluator) notify(rule *Rule, samples []Sample, firing bool) {
+	description := rule.Description
+
+	if tmpl, err := template.New(rule.Name).Parse(rule.Description); err == nil {
+		var rendered bytes.Buffer
+		if execErr := tmpl.Execute(&rendered, templateData{Rule: rule, Samples: samples, Firing: firing, Time: time.Now()}); execErr == nil {
+			description = rendered.String()
+		}
+	}
+
+	status := "FIRING"
+	if !firing {
+		status = "RESOLVED"
+	}
+
+	notify.SendErrorNotification(notify.ErrorNotification{rule.Name,
+		status,
+		"",
+		rule.Summary,
+		description})
+}
@@ -0,0 +1,61 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluatorStateMachine(t *testing.T) {
+	rule := &Rule{
+		Name:       "too-many-failures",
+		RequestIds: []int{1},
+		Condition:  ConsecutiveFailures(2),
+		For:        0,
+		Severity:   "critical",
+		Summary:    "request 1 is failing",
+	}
+
+	evaluator := NewEvaluator([]*Rule{rule})
+
+	evaluator.Record(1, Sample{Time: time.Now(), Outcome: OutcomeSuccess})
+	if rule.state != StateInactive {
+		t.Fatalf("expected inactive after a success, got %s", rule.state)
+	}
+
+	evaluator.Record(1, Sample{Time: time.Now(), Outcome: OutcomeTransportError})
+	if rule.state != StateInactive {
+		t.Fatalf("expected inactive after a single failure, got %s", rule.state)
+	}
+
+	evaluator.Record(1, Sample{Time: time.Now(), Outcome: OutcomeTransportError})
+	if rule.state != StateFiring {
+		t.Fatalf("expected firing once the condition matches with For=0, got %s", rule.state)
+	}
+
+	evaluator.Record(1, Sample{Time: time.Now(), Outcome: OutcomeSuccess})
+	if rule.state != StateInactive {
+		t.Fatalf("expected inactive again once the condition stops matching, got %s", rule.state)
+	}
+}
+
+func TestEvaluatorRespectsForDuration(t *testing.T) {
+	rule := &Rule{
+		Name:       "slow-to-fire",
+		RequestIds: []int{1},
+		Condition:  ConsecutiveFailures(1),
+		For:        50 * time.Millisecond,
+	}
+
+	evaluator := NewEvaluator([]*Rule{rule})
+
+	evaluator.Record(1, Sample{Time: time.Now(), Outcome: OutcomeTransportError})
+	if rule.state != StatePending {
+		t.Fatalf("expected pending while For hasn't elapsed, got %s", rule.state)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	evaluator.Record(1, Sample{Time: time.Now(), Outcome: OutcomeTransportError})
+	if rule.state != StateFiring {
+		t.Fatalf("expected firing once For has elapsed, got %s", rule.state)
+	}
+}
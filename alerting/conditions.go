@@ -0,0 +1,126 @@
+package alerting
+
+import (
+	"sort"
+	"time"
+)
+
+//ConsecutiveFailures matches when the most recent n samples are all non-success
+func ConsecutiveFailures(n int) Condition {
+	return func(samples []Sample) bool {
+		if len(samples) < n {
+			return false
+		}
+
+		for _, sample := range samples[len(samples)-n:] {
+			if sample.Outcome == OutcomeSuccess {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+//PercentileResponseTimeAbove matches when the given percentile (0-100) of latencies observed
+//within the last `within` duration exceeds threshold
+func PercentileResponseTimeAbove(percentile float64, threshold time.Duration, within time.Duration) Condition {
+	return func(samples []Sample) bool {
+		recent := samplesWithin(samples, within)
+		if len(recent) == 0 {
+			return false
+		}
+
+		return percentileLatency(recent, percentile) > threshold
+	}
+}
+
+//OutcomeWithin matches when every sample observed within the last `within` duration has the given outcome
+func OutcomeWithin(outcome string, within time.Duration) Condition {
+	return func(samples []Sample) bool {
+		recent := samplesWithin(samples, within)
+		if len(recent) == 0 {
+			return false
+		}
+
+		for _, sample := range recent {
+			if sample.Outcome != outcome {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+//FailureRatioAbove matches when the fraction of non-success samples observed within the last
+//`within` duration exceeds ratio
+func FailureRatioAbove(ratio float64, within time.Duration) Condition {
+	return func(samples []Sample) bool {
+		recent := samplesWithin(samples, within)
+		if len(recent) == 0 {
+			return false
+		}
+
+		failures := 0
+		for _, sample := range recent {
+			if sample.Outcome != OutcomeSuccess {
+				failures++
+			}
+		}
+
+		return float64(failures)/float64(len(recent)) > ratio
+	}
+}
+
+//OutcomeRatioAbove matches when the fraction of samples observed within the last `within`
+//duration whose outcome equals outcome exceeds ratio
+func OutcomeRatioAbove(outcome string, ratio float64, within time.Duration) Condition {
+	return func(samples []Sample) bool {
+		recent := samplesWithin(samples, within)
+		if len(recent) == 0 {
+			return false
+		}
+
+		matching := 0
+		for _, sample := range recent {
+			if sample.Outcome == outcome {
+				matching++
+			}
+		}
+
+		return float64(matching)/float64(len(recent)) > ratio
+	}
+}
+
+func samplesWithin(samples []Sample, within time.Duration) []Sample {
+	cutoff := time.Now().Add(-within)
+
+	var recent []Sample
+	for _, sample := range samples {
+		if sample.Time.After(cutoff) {
+			recent = append(recent, sample)
+		}
+	}
+
+	return recent
+}
+
+func percentileLatency(samples []Sample, percentile float64) time.Duration {
+	latencies := make([]time.Duration, len(samples))
+	for i, sample := range samples {
+		latencies[i] = sample.Latency
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	idx := int(percentile / 100 * float64(len(latencies)-1))
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(latencies) {
+		idx = len(latencies) - 1
+	}
+
+	return latencies[idx]
+}
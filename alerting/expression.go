@@ -0,0 +1,171 @@
+package alerting
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+//exprPattern matches "<metric> <op> <value>" with an optional trailing "for <duration>"
+//window, eg. "consecutive_failures >= 3" or "p95_response_time_ms > 500 for 5m"
+var exprPattern = regexp.MustCompile(`^\s*([a-zA-Z0-9_]+)\s*(>=|<=|==|!=|>|<)\s*([0-9]+(?:\.[0-9]+)?)\s*(?:for\s+([0-9a-zA-Z]+))?\s*$`)
+
+//outcomeWindowPattern matches "<outcome> for <duration>" with no comparison at all, eg.
+//"body_invalid for 2m" - true when every sample in the window has that outcome
+var outcomeWindowPattern = regexp.MustCompile(`^\s*([a-zA-Z0-9_]+)\s+for\s+([0-9a-zA-Z]+)\s*$`)
+
+//ratioOverPattern matches "<metric> <op> <value> ratio <op> <ratio> over <duration>", eg.
+//"code != 200 ratio > 0.1 over 10m"
+var ratioOverPattern = regexp.MustCompile(`^\s*([a-zA-Z0-9_]+)\s*(>=|<=|==|!=|>|<)\s*([0-9]+(?:\.[0-9]+)?)\s*ratio\s*(>=|<=|==|!=|>|<)\s*([0-9]+(?:\.[0-9]+)?)\s*over\s+([0-9a-zA-Z]+)\s*$`)
+
+//percentileMetricPattern extracts the percentile out of a metric name like p95_response_time_ms
+var percentileMetricPattern = regexp.MustCompile(`^p([0-9]{1,3})_response_time_ms$`)
+
+//ParseExpression compiles a config-declared rule expression into a Condition. Three forms are
+//supported:
+//
+//	consecutive_failures >= 3
+//	p95_response_time_ms > 500 for 5m
+//	body_invalid for 2m
+//	code != 200 ratio > 0.1 over 10m
+//
+//The first form is "<metric> <op> <value>" with an optional trailing "for <duration>" window
+//("for <duration>" is the window the metric is aggregated over - it's unrelated to Rule.For,
+//which governs how long the condition must keep matching before the rule fires). It's required
+//for every metric except consecutive_failures, which looks only at the most recent samples.
+//Only > and >= are supported there, since every such metric is a threshold check.
+//
+//The second form, "<outcome> for <duration>", fires when every sample in the window has the
+//given outcome (one of success, code_mismatch, body_invalid, transport_error).
+//
+//The third form, "<metric> <op> <value> ratio <op> <ratio> over <duration>", fires when the
+//fraction of samples matching "<metric> <op> <value>" within the window exceeds the ratio
+//threshold. The only metric currently wired up is "code" with the "!=" operator: Sample doesn't
+//retain the actual HTTP status code, so "code != <n>" is treated as a synonym for the
+//code_mismatch outcome regardless of which status n names - it exists so rules can be written the
+//way an operator would say them, not because individual status codes are tracked.
+func ParseExpression(expr string) (Condition, error) {
+	if matches := ratioOverPattern.FindStringSubmatch(expr); matches != nil {
+		return parseRatioOverWindow(expr, matches)
+	}
+
+	if matches := exprPattern.FindStringSubmatch(expr); matches != nil {
+		return parseThresholdExpr(expr, matches)
+	}
+
+	if matches := outcomeWindowPattern.FindStringSubmatch(expr); matches != nil {
+		return parseOutcomeWindow(expr, matches)
+	}
+
+	return nil, fmt.Errorf("alerting: invalid rule expression %q", expr)
+}
+
+func parseThresholdExpr(expr string, matches []string) (Condition, error) {
+	metric, op, valueStr, withinStr := matches[1], matches[2], matches[3], matches[4]
+
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("alerting: invalid value in expression %q: %w", expr, err)
+	}
+
+	if op != ">" && op != ">=" {
+		return nil, fmt.Errorf("alerting: expression %q uses %q, only > and >= are supported", expr, op)
+	}
+
+	var within time.Duration
+	if withinStr != "" {
+		within, err = time.ParseDuration(withinStr)
+		if err != nil {
+			return nil, fmt.Errorf("alerting: invalid duration in expression %q: %w", expr, err)
+		}
+	}
+
+	if percentile, ok := parsePercentileMetric(metric); ok {
+		if within == 0 {
+			return nil, fmt.Errorf("alerting: expression %q needs a \"for <duration>\" window", expr)
+		}
+
+		return PercentileResponseTimeAbove(percentile, time.Duration(value)*time.Millisecond, within), nil
+	}
+
+	switch metric {
+	case "consecutive_failures":
+		n := int(value)
+		if op == ">" {
+			n++
+		}
+
+		return ConsecutiveFailures(n), nil
+	case "failure_ratio":
+		if within == 0 {
+			return nil, fmt.Errorf("alerting: expression %q needs a \"for <duration>\" window", expr)
+		}
+
+		return FailureRatioAbove(value, within), nil
+	default:
+		return nil, fmt.Errorf("alerting: unknown metric %q in expression %q", metric, expr)
+	}
+}
+
+func parseOutcomeWindow(expr string, matches []string) (Condition, error) {
+	outcome, withinStr := matches[1], matches[2]
+
+	if !isKnownOutcome(outcome) {
+		return nil, fmt.Errorf("alerting: unknown outcome %q in expression %q", outcome, expr)
+	}
+
+	within, err := time.ParseDuration(withinStr)
+	if err != nil {
+		return nil, fmt.Errorf("alerting: invalid duration in expression %q: %w", expr, err)
+	}
+
+	return OutcomeWithin(outcome, within), nil
+}
+
+func parseRatioOverWindow(expr string, matches []string) (Condition, error) {
+	metric, matchOp, _, ratioOp, ratioStr, withinStr := matches[1], matches[2], matches[3], matches[4], matches[5], matches[6]
+
+	if metric != "code" || matchOp != "!=" {
+		return nil, fmt.Errorf("alerting: expression %q: only \"code != <n>\" is supported before \"ratio\"", expr)
+	}
+
+	if ratioOp != ">" && ratioOp != ">=" {
+		return nil, fmt.Errorf("alerting: expression %q uses %q, only > and >= are supported for the ratio threshold", expr, ratioOp)
+	}
+
+	ratio, err := strconv.ParseFloat(ratioStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("alerting: invalid ratio in expression %q: %w", expr, err)
+	}
+
+	within, err := time.ParseDuration(withinStr)
+	if err != nil {
+		return nil, fmt.Errorf("alerting: invalid duration in expression %q: %w", expr, err)
+	}
+
+	return OutcomeRatioAbove(OutcomeCodeMismatch, ratio, within), nil
+}
+
+func isKnownOutcome(outcome string) bool {
+	switch outcome {
+	case OutcomeSuccess, OutcomeCodeMismatch, OutcomeBodyInvalid, OutcomeTransportError:
+		return true
+	default:
+		return false
+	}
+}
+
+func parsePercentileMetric(metric string) (float64, bool) {
+	m := percentileMetricPattern.FindStringSubmatch(metric)
+	if m == nil {
+		return 0, false
+	}
+
+	percentile, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return percentile, true
+}
@@ -0,0 +1,184 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpression(t *testing.T) {
+	cases := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"consecutive_failures >= 3", false},
+		{"consecutive_failures > 3", false},
+		{"p95_response_time_ms > 500 for 5m", false},
+		{"failure_ratio > 0.5 for 1m", false},
+		{"p95_response_time_ms > 500", true}, // missing required window
+		{"consecutive_failures == 3", true},  // unsupported operator
+		{"unknown_metric > 1 for 1m", true},  // unknown metric
+		{"not an expression", true},          // doesn't match the grammar at all
+	}
+
+	for _, c := range cases {
+		_, err := ParseExpression(c.expr)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseExpression(%q) error = %v, wantErr %v", c.expr, err, c.wantErr)
+		}
+	}
+}
+
+func TestParseExpressionConsecutiveFailuresMatchesSemantics(t *testing.T) {
+	cond, err := ParseExpression("consecutive_failures >= 2")
+	if err != nil {
+		t.Fatalf("ParseExpression: %v", err)
+	}
+
+	samples := []Sample{
+		{Time: time.Now(), Outcome: OutcomeTransportError},
+		{Time: time.Now(), Outcome: OutcomeTransportError},
+	}
+
+	if !cond(samples) {
+		t.Error("expected consecutive_failures >= 2 to match two trailing failures")
+	}
+
+	if cond(samples[:1]) {
+		t.Error("expected consecutive_failures >= 2 to not match a single failure")
+	}
+}
+
+func TestRuleConditionPrefersGoCondition(t *testing.T) {
+	called := false
+	rule := &Rule{
+		Expression: "not an expression",
+		Condition: func(samples []Sample) bool {
+			called = true
+			return false
+		},
+	}
+
+	cond, err := rule.condition()
+	if err != nil {
+		t.Fatalf("condition: %v", err)
+	}
+
+	cond(nil)
+	if !called {
+		t.Error("expected the Go-level Condition to take precedence over an (invalid) Expression")
+	}
+}
+
+func TestRuleConditionCompilesExpression(t *testing.T) {
+	rule := &Rule{Expression: "consecutive_failures >= 1"}
+
+	cond, err := rule.condition()
+	if err != nil {
+		t.Fatalf("condition: %v", err)
+	}
+
+	if !cond([]Sample{{Time: time.Now(), Outcome: OutcomeTransportError}}) {
+		t.Error("expected the compiled expression to match a single failure")
+	}
+}
+
+func TestRuleConditionReportsBadExpression(t *testing.T) {
+	rule := &Rule{Expression: "not an expression"}
+
+	if _, err := rule.condition(); err == nil {
+		t.Error("expected an error for an unparseable expression")
+	}
+}
+
+func TestParseExpressionOutcomeWindow(t *testing.T) {
+	cases := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"body_invalid for 2m", false},
+		{"transport_error for 30s", false},
+		{"not_an_outcome for 2m", true}, // unknown outcome
+		{"body_invalid for nope", true}, // unparseable duration
+	}
+
+	for _, c := range cases {
+		_, err := ParseExpression(c.expr)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseExpression(%q) error = %v, wantErr %v", c.expr, err, c.wantErr)
+		}
+	}
+}
+
+func TestParseExpressionOutcomeWindowMatchesSemantics(t *testing.T) {
+	cond, err := ParseExpression("body_invalid for 2m")
+	if err != nil {
+		t.Fatalf("ParseExpression: %v", err)
+	}
+
+	now := time.Now()
+	allInvalid := []Sample{
+		{Time: now, Outcome: OutcomeBodyInvalid},
+		{Time: now, Outcome: OutcomeBodyInvalid},
+	}
+	mixed := []Sample{
+		{Time: now, Outcome: OutcomeBodyInvalid},
+		{Time: now, Outcome: OutcomeSuccess},
+	}
+
+	if !cond(allInvalid) {
+		t.Error("expected body_invalid for 2m to match when every recent sample is body_invalid")
+	}
+
+	if cond(mixed) {
+		t.Error("expected body_invalid for 2m to not match once a sample has a different outcome")
+	}
+}
+
+func TestParseExpressionRatioOverWindow(t *testing.T) {
+	cases := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"code != 200 ratio > 0.1 over 10m", false},
+		{"code != 200 ratio >= 0.1 over 10m", false},
+		{"code == 200 ratio > 0.1 over 10m", true},  // only != is wired up before "ratio"
+		{"code != 200 ratio == 0.1 over 10m", true}, // only > and >= are wired up for the ratio
+		{"status != 200 ratio > 0.1 over 10m", true}, // only "code" is a supported metric
+	}
+
+	for _, c := range cases {
+		_, err := ParseExpression(c.expr)
+		if (err != nil) != c.wantErr {
+			t.Errorf("ParseExpression(%q) error = %v, wantErr %v", c.expr, err, c.wantErr)
+		}
+	}
+}
+
+func TestParseExpressionRatioOverWindowMatchesSemantics(t *testing.T) {
+	cond, err := ParseExpression("code != 200 ratio > 0.25 over 10m")
+	if err != nil {
+		t.Fatalf("ParseExpression: %v", err)
+	}
+
+	now := time.Now()
+	mostlyMismatched := []Sample{
+		{Time: now, Outcome: OutcomeCodeMismatch},
+		{Time: now, Outcome: OutcomeCodeMismatch},
+		{Time: now, Outcome: OutcomeSuccess},
+	}
+	mostlySuccess := []Sample{
+		{Time: now, Outcome: OutcomeCodeMismatch},
+		{Time: now, Outcome: OutcomeSuccess},
+		{Time: now, Outcome: OutcomeSuccess},
+		{Time: now, Outcome: OutcomeSuccess},
+		{Time: now, Outcome: OutcomeSuccess},
+	}
+
+	if !cond(mostlyMismatched) {
+		t.Error("expected the ratio condition to match when code_mismatch is 2/3 of recent samples")
+	}
+
+	if cond(mostlySuccess) {
+		t.Error("expected the ratio condition to not match when code_mismatch is only 1/5 of recent samples")
+	}
+}
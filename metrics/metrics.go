@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+//Result labels used on statusok_requests_total
+const (
+	ResultSuccess        = "success"
+	ResultCodeMismatch   = "code_mismatch"
+	ResultBodyInvalid    = "body_invalid"
+	ResultTransportError = "transport_error"
+)
+
+//DefaultBuckets is used when Config.Buckets is not set
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+//DefaultListenAddress is used when Config.ListenAddress is not set
+const DefaultListenAddress = ":9091"
+
+//Config controls whether statusok exposes Prometheus metrics and how they are labeled
+type Config struct {
+	Enabled       bool              `json:"enabled"`
+	ListenAddress string            `json:"listenAddress"`
+	Buckets       []float64         `json:"buckets"`
+	StaticLabels  map[string]string `json:"staticLabels"`
+}
+
+var (
+	//registry is a dedicated registry rather than the global default one, so that calling Init
+	//again (eg. after a Shutdown/restart in the same process) can simply swap the registered
+	//collectors for new ones instead of panicking on "duplicate metrics collector registration"
+	registry = prometheus.NewRegistry()
+
+	requestDuration *prometheus.HistogramVec
+	requestsTotal   *prometheus.CounterVec
+	requestRetries  *prometheus.CounterVec
+	lastSuccess     *prometheus.GaugeVec
+	budgetRatio     *prometheus.GaugeVec
+)
+
+//Init registers the statusok collectors and, if config.Enabled, starts the /metrics
+//HTTP listener on config.ListenAddress. It is safe to call Init with Enabled false -
+//collectors are still registered so ObserveRequest/ObserveRetry can be called
+//unconditionally from the requests package. Init is also safe to call more than once
+//(eg. across a Shutdown/RequestsInit restart) - the previous collectors are unregistered
+//before the new ones take their place.
+func Init(config Config) error {
+	buckets := config.Buckets
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	constLabels := prometheus.Labels{}
+	for key, value := range config.StaticLabels {
+		constLabels[key] = value
+	}
+
+	unregisterAll()
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "statusok_request_duration_seconds",
+		Help:        "Time taken to perform a monitored request, in seconds.",
+		Buckets:     buckets,
+		ConstLabels: constLabels,
+	}, []string{"url", "method", "response_code"})
+
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "statusok_requests_total",
+		Help:        "Count of monitored requests by outcome.",
+		ConstLabels: constLabels,
+	}, []string{"url", "method", "result"})
+
+	requestRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "statusok_request_retries_total",
+		Help:        "Count of retry attempts made before a request succeeded or was given up on.",
+		ConstLabels: constLabels,
+	}, []string{"url", "method"})
+
+	lastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "statusok_last_success_timestamp",
+		Help:        "Unix timestamp of the last successful request.",
+		ConstLabels: constLabels,
+	}, []string{"url", "method"})
+
+	budgetRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "statusok_response_time_budget_ratio",
+		Help:        "Elapsed response time divided by the configured response time budget.",
+		ConstLabels: constLabels,
+	}, []string{"url", "method"})
+
+	registry.MustRegister(requestDuration, requestsTotal, requestRetries, lastSuccess, budgetRatio)
+
+	if !config.Enabled {
+		return nil
+	}
+
+	addr := config.ListenAddress
+	if addr == "" {
+		addr = DefaultListenAddress
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Println("metrics: listener stopped :", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+//unregisterAll removes any collectors registered by a previous Init call, so Init can be
+//called again without panicking. Each collector is checked for nil before being wrapped in
+//the prometheus.Collector interface - a nil *HistogramVec etc. boxed in an interface is a
+//non-nil interface value, so the checks have to happen on the concrete types first.
+func unregisterAll() {
+	if requestDuration != nil {
+		registry.Unregister(requestDuration)
+	}
+	if requestsTotal != nil {
+		registry.Unregister(requestsTotal)
+	}
+	if requestRetries != nil {
+		registry.Unregister(requestRetries)
+	}
+	if lastSuccess != nil {
+		registry.Unregister(lastSuccess)
+	}
+	if budgetRatio != nil {
+		registry.Unregister(budgetRatio)
+	}
+}
+
+//ObserveRequest records the final outcome of a monitored request, once any retries are done
+func ObserveRequest(url string, method string, responseCode int, elapsed time.Duration, result string, budget float64) {
+	if requestDuration == nil {
+		//Init was never called, nothing to record
+		return
+	}
+
+	code := strconv.Itoa(responseCode)
+	requestDuration.WithLabelValues(url, method, code).Observe(elapsed.Seconds())
+	requestsTotal.WithLabelValues(url, method, result).Inc()
+	budgetRatio.WithLabelValues(url, method).Set(budget)
+
+	if result == ResultSuccess {
+		lastSuccess.WithLabelValues(url, method).Set(float64(time.Now().Unix()))
+	}
+}
+
+//ObserveRetry increments statusok_request_retries_total for a request that is about to be retried
+func ObserveRetry(url string, method string) {
+	if requestRetries == nil {
+		return
+	}
+
+	requestRetries.WithLabelValues(url, method).Inc()
+}
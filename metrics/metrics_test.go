@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInitIsSafeToCallTwice(t *testing.T) {
+	if err := Init(Config{}); err != nil {
+		t.Fatalf("first Init: %v", err)
+	}
+
+	if err := Init(Config{}); err != nil {
+		t.Fatalf("second Init: %v", err)
+	}
+}
+
+func TestObserveRequestAndRetry(t *testing.T) {
+	if err := Init(Config{}); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	ObserveRequest("http://example.com", "GET", 200, 0, ResultSuccess, 0.5)
+	ObserveRetry("http://example.com", "GET")
+
+	if got := testutil.ToFloat64(requestsTotal.WithLabelValues("http://example.com", "GET", ResultSuccess)); got != 1 {
+		t.Errorf("requestsTotal = %v, want 1", got)
+	}
+
+	if got := testutil.ToFloat64(requestRetries.WithLabelValues("http://example.com", "GET")); got != 1 {
+		t.Errorf("requestRetries = %v, want 1", got)
+	}
+}
@@ -2,25 +2,46 @@ package requests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"statusok/alerting"
 	"statusok/database"
+	"statusok/metrics"
 	"statusok/notify"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"sync"
 	"time"
 	"strings"
-	"github.com/tidwall/gjson"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 var (
 	RequestsList   []RequestConfig
 	requestChannel chan RequestConfig
 	throttle       chan int
+
+	monitorCtx    context.Context
+	monitorCancel context.CancelFunc
+	inFlight      sync.WaitGroup
+
+	tickerQuitMu sync.Mutex
+	tickerQuits  []chan struct{}
+	tickerWG     sync.WaitGroup
+
+	shutdownOnce sync.Once
+	shutdownDone chan struct{}
+
+	alertEvaluator *alerting.Evaluator
 )
 
 const (
@@ -45,16 +66,128 @@ type RequestConfig struct {
 	ResponseTime int64             `json:"responseTime"`
 	ResponseBody []ResponseConfig  `json:"responseBody"`
 	CheckEvery   time.Duration     `json:"checkEvery"`
+	RetryPolicy  RetryPolicy       `json:"retryPolicy"`
+	Timeout      time.Duration     `json:"timeout"`
+	BodyCapture  BodyCaptureConfig `json:"bodyCapture"`
+}
+
+//BodyCaptureConfig controls how much of a response body doAttempt reads into memory and
+//whether it's kept around once ValidateResponse has run
+type BodyCaptureConfig struct {
+	MaxBytes  int64  `json:"maxBytes"`
+	CaptureOn string `json:"captureOn"`
+
+	//SkipStringCopy avoids materializing the full response body at all where it can: when
+	//CaptureOn is CaptureAlways and every ResponseBody config uses a flat top-level gjson key
+	//(see ValidateResponseStream), doAttempt validates straight off the live response reader
+	//instead of buffering it first. Otherwise it falls back to validating directly off the
+	//captured []byte instead of paying for a []byte -> string copy first - in that fallback,
+	//MaxBytes, not this flag, is what bounds memory use for a large body.
+	SkipStringCopy bool `json:"skipStringCopy"`
+}
+
+//CaptureOn values for BodyCaptureConfig
+const (
+	CaptureAlways  = "always"
+	CaptureOnError = "on_error"
+	CaptureNever   = "never"
+)
+
+//DefaultBodyCaptureMaxBytes caps how much of a response body is read into memory when
+//BodyCaptureConfig.MaxBytes isn't set
+const DefaultBodyCaptureMaxBytes = 64 * 1024
+
+//RetryPolicy controls whether a failed attempt in PerformRequest is retried before
+//being persisted to the database and notified on
+type RetryPolicy struct {
+	MaxAttempts     int           `json:"maxAttempts"`
+	InitialInterval time.Duration `json:"initialInterval"`
+	Multiplier      float64       `json:"multiplier"`
+	MaxInterval     time.Duration `json:"maxInterval"`
+	MaxElapsed      time.Duration `json:"maxElapsed"`
+	RetryOn         []string      `json:"retryOn"`
+}
+
+//Outcome classes a RetryPolicy.RetryOn entry can reference, alongside numeric status codes
+const (
+	RetryOnTransport    = "transport"
+	RetryOnTimeout      = "timeout"
+	RetryOnCodeMismatch = "code_mismatch"
+	RetryOnBodyInvalid  = "body_invalid"
+)
+
+//Defaults applied to a zero-valued RetryPolicy, ie. a RequestConfig with no retryPolicy set
+const (
+	DefaultMaxAttempts     = 1
+	DefaultInitialInterval = 500 * time.Millisecond
+	DefaultMultiplier      = 2.0
+	DefaultMaxInterval     = 30 * time.Second
+)
+
+//DefaultMaxTimeout caps the per-attempt timeout derived from ResponseTime when Timeout isn't set
+const DefaultMaxTimeout = 30 * time.Second
+
+//backoff returns the base backoff interval (before jitter) for the given 0-indexed attempt
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	interval := float64(policy.InitialInterval) * math.Pow(policy.Multiplier, float64(attempt))
+	if max := float64(policy.MaxInterval); interval > max {
+		interval = max
+	}
+
+	return time.Duration(interval)
+}
+
+//retryable reports whether RetryOn allows retrying an outcome of the given class or status code
+func (policy RetryPolicy) retryable(class string, statusCode int) bool {
+	for _, retryOn := range policy.RetryOn {
+		if retryOn == class {
+			return true
+		}
+		if statusCode != 0 && retryOn == strconv.Itoa(statusCode) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//attemptResult is the outcome of a single doAttempt call. class is empty on success.
+type attemptResult struct {
+	statusCode    int
+	respBody      string
+	elapsed       time.Duration
+	class         string
+	reason        error
+	otherInfo     string
+	validationMsg string
 }
 
 type ResponseConfig struct {
 	ResponseValue         string
 	Valid                 bool
-	Key                   string      `json:key`
-	Empty                 bool        `json:isEmpty`
-	CharCountGreaterThan  int64       `json:charCountGreaterThan`
-	Int                   bool        `json:isInt`
-	ErrorMessage          []string
+	Key                   string      `json:"key"`
+	Empty                 bool        `json:"isEmpty"`
+
+	//CharCountGreaterThan is deprecated in favor of LengthBetween. It errors when the response
+	//value's character count is less than or equal to the threshold.
+	CharCountGreaterThan  int64       `json:"charCountGreaterThan"`
+	Int                   bool        `json:"isInt"`
+
+	Equals        string           `json:"equals"`
+	NotEquals     string           `json:"notEquals"`
+	MatchesRegex  string           `json:"matchesRegex"`
+	LessThan      *float64         `json:"lessThan"`
+	GreaterThan   *float64         `json:"greaterThan"`
+	OneOf         []string         `json:"oneOf"`
+	LengthBetween [2]int           `json:"lengthBetween"`
+	JSONSchema    string           `json:"jsonSchema"`
+	AllOf         []ResponseConfig `json:"allOf"`
+	AnyOf         []ResponseConfig `json:"anyOf"`
+
+	ErrorMessage []string
+
+	compiledRegex  *regexp.Regexp
+	compiledSchema *gojsonschema.Schema
 }
 
 //Set Id for request
@@ -90,13 +223,62 @@ func (requestConfig *RequestConfig) Validate() error {
 		requestConfig.CheckEvery = defTime
 	}
 
+	if requestConfig.RetryPolicy.MaxAttempts == 0 {
+		requestConfig.RetryPolicy.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if requestConfig.RetryPolicy.InitialInterval == 0 {
+		requestConfig.RetryPolicy.InitialInterval = DefaultInitialInterval
+	}
+
+	if requestConfig.RetryPolicy.Multiplier == 0 {
+		requestConfig.RetryPolicy.Multiplier = DefaultMultiplier
+	}
+
+	if requestConfig.RetryPolicy.MaxInterval == 0 {
+		requestConfig.RetryPolicy.MaxInterval = DefaultMaxInterval
+	}
+
+	if requestConfig.Timeout == 0 {
+		timeout := time.Duration(requestConfig.ResponseTime) * time.Millisecond * 2
+		if timeout > DefaultMaxTimeout {
+			timeout = DefaultMaxTimeout
+		}
+		requestConfig.Timeout = timeout
+	}
+
+	if requestConfig.BodyCapture.MaxBytes == 0 {
+		requestConfig.BodyCapture.MaxBytes = DefaultBodyCaptureMaxBytes
+	}
+
+	if requestConfig.BodyCapture.CaptureOn == "" {
+		requestConfig.BodyCapture.CaptureOn = CaptureAlways
+	}
+
+	if err := compileResponseConfigs(requestConfig.ResponseBody); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 //Initialize data from config file and check all requests
-func RequestsInit(data []RequestConfig, concurrency int) {
+func RequestsInit(data []RequestConfig, concurrency int, metricsConfig metrics.Config, alertRules []*alerting.Rule) {
 	RequestsList = data
 
+	//reset in case this is a restart after a prior Shutdown, ie. RequestsInit is being called
+	//again in the same process
+	shutdownOnce = sync.Once{}
+
+	monitorCtx, monitorCancel = context.WithCancel(context.Background())
+
+	alertEvaluator = alerting.NewEvaluator(alertRules)
+
+	if metricsErr := metrics.Init(metricsConfig); metricsErr != nil {
+		println("\nFailed to initialize metrics :", metricsErr.Error())
+		os.Exit(3)
+	}
+
 	//throttle channel is used to limit number of requests performed at a time
 	if concurrency == 0 {
 		throttle = make(chan int, DefaultConcurrency)
@@ -118,7 +300,7 @@ func RequestsInit(data []RequestConfig, concurrency int) {
 		println("Request #", i, " : ", requestConfig.RequestType, " ", requestConfig.Url)
 
 		//Perform request
-		reqErr := PerformRequest(requestConfig, nil)
+		reqErr := PerformRequest(monitorCtx, requestConfig, nil)
 
 		if reqErr != nil {
 			//Request Failed
@@ -139,46 +321,119 @@ func RequestsInit(data []RequestConfig, concurrency int) {
 func StartMonitoring() {
 	fmt.Println("\nStarted Monitoring all ", len(RequestsList), " apis .....")
 
-	go listenToRequestChannel()
+	go listenToRequestChannel(monitorCtx)
 
 	for _, requestConfig := range RequestsList {
-		go createTicker(requestConfig)
+		quit := make(chan struct{})
+
+		tickerQuitMu.Lock()
+		tickerQuits = append(tickerQuits, quit)
+		tickerQuitMu.Unlock()
+
+		tickerWG.Add(1)
+		go createTicker(requestConfig, quit)
 	}
 }
 
-//A time ticker writes data to request channel for every request.CheckEvery seconds
-func createTicker(requestConfig RequestConfig) {
+//A time ticker writes data to request channel for every request.CheckEvery seconds,
+//until quit is closed by Shutdown. Shutdown waits for createTicker to return via
+//tickerWG before it closes requestChannel, so a send here never races a close.
+func createTicker(requestConfig RequestConfig, quit chan struct{}) {
+	defer tickerWG.Done()
 
 	var ticker *time.Ticker = time.NewTicker(requestConfig.CheckEvery * time.Second)
-	quit := make(chan struct{})
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			requestChannel <- requestConfig
+			select {
+			case requestChannel <- requestConfig:
+			case <-quit:
+				return
+			}
 		case <-quit:
-			ticker.Stop()
 			return
 		}
 	}
 }
 
 //all tickers write to request channel
-//here we listen to request channel and perfom each request
-func listenToRequestChannel() {
+//here we listen to request channel and perfom each request, until ctx is cancelled
+func listenToRequestChannel(ctx context.Context) {
 
 	//throttle is used to limit number of requests executed at a time
 	for {
 		select {
-		case requect := <-requestChannel:
-			throttle <- 1
-			go PerformRequest(requect, throttle)
+		case requestConfig, ok := <-requestChannel:
+			if !ok {
+				return
+			}
+
+			select {
+			case throttle <- 1:
+			case <-ctx.Done():
+				return
+			}
+
+			inFlight.Add(1)
+			go func(rc RequestConfig) {
+				defer inFlight.Done()
+				PerformRequest(ctx, rc, throttle)
+			}(requestConfig)
+		case <-ctx.Done():
+			return
 		}
 	}
 
 }
 
-//takes the date from requestConfig and creates http request and executes it
-func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
+//Shutdown stops all tickers, stops accepting new requests and waits for in-flight requests to
+//finish or ctx to expire, whichever happens first. It's safe to call more than once (and
+//concurrently) - shutdownOnce guards the channel closes so only the first call does them, and
+//every call just waits on the same shutdownDone.
+func Shutdown(ctx context.Context) error {
+	shutdownOnce.Do(func() {
+		shutdownDone = make(chan struct{})
+
+		go func() {
+			defer close(shutdownDone)
+
+			if monitorCancel != nil {
+				monitorCancel()
+			}
+
+			tickerQuitMu.Lock()
+			for _, quit := range tickerQuits {
+				close(quit)
+			}
+			tickerQuits = nil
+			tickerQuitMu.Unlock()
+
+			//wait for every ticker goroutine to actually exit before closing requestChannel,
+			//otherwise a ticker could still be sending to it and panic on a closed channel
+			tickerWG.Wait()
+
+			if requestChannel != nil {
+				close(requestChannel)
+			}
+
+			//waits for in-flight requests, each of which frees its throttle slot as it finishes
+			inFlight.Wait()
+		}()
+	})
+
+	select {
+	case <-shutdownDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//takes the date from requestConfig and creates http request and executes it.
+//Transient failures are retried per requestConfig.RetryPolicy before being
+//persisted to the database and notified on.
+func PerformRequest(ctx context.Context, requestConfig RequestConfig, throttle chan int) error {
 	//Remove value from throttel channel when request is completed
 	defer func() {
 		if throttle != nil {
@@ -186,12 +441,255 @@ func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 		}
 	}()
 
+	policy := requestConfig.RetryPolicy
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = DefaultMaxAttempts
+	}
+
+	overallStart := time.Now()
+	var result attemptResult
+
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		result = doAttempt(ctx, requestConfig)
+
+		if result.class == "" {
+			//Request succeeded
+			break
+		}
+
+		lastAttempt := attempt == policy.MaxAttempts-1
+		elapsedOverall := time.Since(overallStart)
+		exhausted := lastAttempt || (policy.MaxElapsed != 0 && elapsedOverall >= policy.MaxElapsed)
+
+		if exhausted || !policy.retryable(result.class, result.statusCode) {
+			break
+		}
+
+		metrics.ObserveRetry(requestConfig.Url, requestConfig.RequestType)
+
+		wait := policy.backoff(attempt)
+		if wait > 0 {
+			//full jitter
+			wait = time.Duration(rand.Int63n(int64(wait)))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	budget := responseTimeBudgetRatio(result.elapsed, requestConfig)
+
+	if result.class == "" {
+		metrics.ObserveRequest(requestConfig.Url, requestConfig.RequestType, result.statusCode, result.elapsed, metrics.ResultSuccess, budget)
+		recordSample(requestConfig.Id, alerting.OutcomeSuccess, result.elapsed)
+
+		//Request succesfull . Add infomartion to Database
+		go database.AddRequestInfo(database.RequestInfo{
+			Id:                   requestConfig.Id,
+			Url:                  requestConfig.Url,
+			RequestType:          requestConfig.RequestType,
+			ResponseCode:         result.statusCode,
+			ResponseTime:         result.elapsed.Nanoseconds() / 1000000,
+			ExpectedResponseTime: requestConfig.ResponseTime,
+		})
+
+		return nil
+	}
+
+	//Retries (if any) are exhausted, or this outcome isn't eligible for retry - persist and notify
+	if result.class == RetryOnBodyInvalid {
+		metrics.ObserveRequest(requestConfig.Url, requestConfig.RequestType, result.statusCode, result.elapsed, metrics.ResultBodyInvalid, budget)
+		recordSample(requestConfig.Id, alerting.OutcomeBodyInvalid, result.elapsed)
+
+		notify.SendErrorNotification(notify.ErrorNotification{requestConfig.Url,
+			requestConfig.RequestType,
+			result.respBody,
+			"Response Body Validation",
+			result.validationMsg})
+
+		return errors.New(result.validationMsg)
+	}
+
+	metricsResult := metrics.ResultTransportError
+	alertOutcome := alerting.OutcomeTransportError
+	if result.class == RetryOnCodeMismatch {
+		metricsResult = metrics.ResultCodeMismatch
+		alertOutcome = alerting.OutcomeCodeMismatch
+	}
+	metrics.ObserveRequest(requestConfig.Url, requestConfig.RequestType, result.statusCode, result.elapsed, metricsResult, budget)
+	recordSample(requestConfig.Id, alertOutcome, result.elapsed)
+
+	go database.AddErrorInfo(database.ErrorInfo{
+		Id:           requestConfig.Id,
+		Url:          requestConfig.Url,
+		RequestType:  requestConfig.RequestType,
+		ResponseCode: result.statusCode,
+		ResponseBody: result.respBody,
+		Reason:       result.reason,
+		OtherInfo:    result.otherInfo,
+	})
+
+	return result.reason
+}
+
+//doAttempt performs a single HTTP round trip plus body validation, bounded by
+//requestConfig.Timeout. It does not touch the database, the notifier or metrics -
+//those only happen once PerformRequest knows whether the attempt will be retried.
+func doAttempt(ctx context.Context, requestConfig RequestConfig) attemptResult {
+	start := time.Now()
+
+	attemptCtx, cancel := context.WithTimeout(ctx, requestConfig.Timeout)
+	defer cancel()
+
+	request, reqErr := buildRequest(attemptCtx, requestConfig)
+	if reqErr != nil {
+		return attemptResult{
+			class:     RetryOnTransport,
+			reason:    database.ErrCreateRequest,
+			otherInfo: reqErr.Error(),
+			elapsed:   time.Since(start),
+		}
+	}
+
+	client := &http.Client{}
+	getResponse, respErr := client.Do(request)
+
+	if respErr != nil {
+		bodyBytes := captureResponseBody(getResponse, requestConfig.BodyCapture)
+		elapsed := time.Since(start)
+
+		var statusCode int
+		if getResponse != nil {
+			statusCode = getResponse.StatusCode
+		}
+
+		class := RetryOnTransport
+		if attemptCtx.Err() == context.DeadlineExceeded {
+			class = RetryOnTimeout
+		} else if netErr, ok := respErr.(net.Error); ok && netErr.Timeout() {
+			class = RetryOnTimeout
+		}
+
+		return attemptResult{
+			statusCode: statusCode,
+			respBody:   string(bodyBytes),
+			elapsed:    elapsed,
+			class:      class,
+			reason:     database.ErrDoRequest,
+			otherInfo:  respErr.Error(),
+		}
+	}
+
+	defer getResponse.Body.Close()
+
+	if getResponse.StatusCode != requestConfig.ResponseCode {
+		bodyBytes := captureResponseBody(getResponse, requestConfig.BodyCapture)
+
+		return attemptResult{
+			statusCode: getResponse.StatusCode,
+			respBody:   string(bodyBytes),
+			elapsed:    time.Since(start),
+			class:      RetryOnCodeMismatch,
+			reason:     errResposeCode(getResponse.StatusCode, requestConfig.ResponseCode),
+		}
+	}
+
+	errMessages, respBody := validateResponseBody(getResponse, requestConfig)
+	elapsed := time.Since(start)
+
+	if len(errMessages) != 0 {
+		var respMessage strings.Builder
+		for _, msg := range errMessages {
+			respMessage.WriteString("\n")
+			respMessage.WriteString(msg)
+		}
+
+		return attemptResult{
+			statusCode:    getResponse.StatusCode,
+			respBody:      respBody,
+			elapsed:       elapsed,
+			class:         RetryOnBodyInvalid,
+			validationMsg: respMessage.String(),
+		}
+	}
+
+	return attemptResult{statusCode: getResponse.StatusCode, elapsed: elapsed}
+}
+
+//validateResponseBody runs requestConfig.ResponseBody's validators against getResponse and
+//returns any error messages, plus a string suitable for attemptResult.respBody. When
+//BodyCapture.SkipStringCopy is set, CaptureOn is CaptureAlways and every config uses a flat
+//top-level key, it validates straight off getResponse.Body via ValidateResponseStream and never
+//materializes the full body. canStreamValidate is checked before anything touches the response
+//body, so the normal buffered captureResponseBody/ValidateResponse(Bytes) path below only ever
+//runs against an untouched reader - once streaming starts, there's no going back to buffering,
+//so a stream failure (eg. the body wasn't actually a top-level JSON object) is reported as an
+//invalid body directly rather than silently falling back.
+func validateResponseBody(getResponse *http.Response, requestConfig RequestConfig) ([]string, string) {
+	capture := requestConfig.BodyCapture
+
+	if capture.SkipStringCopy && capture.CaptureOn == CaptureAlways && canStreamValidate(requestConfig.ResponseBody) {
+		validated, err := ValidateResponseStream(getResponse.Body, requestConfig.ResponseBody)
+		io.Copy(io.Discard, getResponse.Body)
+
+		if err != nil {
+			return []string{fmt.Sprintf("bodyCapture: streaming validation failed: %s", err.Error())}, ""
+		}
+
+		var errMessages []string
+		for i := range validated {
+			errMessages = append(errMessages, validated[i].ErrorMessage...)
+		}
+
+		return errMessages, summarizeStreamed(validated)
+	}
+
+	bodyBytes := captureResponseBody(getResponse, capture)
+
+	var errMessages []string
+	for body := range requestConfig.ResponseBody {
+		var validated ResponseConfig
+		if capture.SkipStringCopy {
+			validated = ValidateResponseBytes(bodyBytes, requestConfig.ResponseBody[body])
+		} else {
+			validated = ValidateResponse(string(bodyBytes), requestConfig.ResponseBody[body])
+		}
+		errMessages = append(errMessages, validated.ErrorMessage...)
+	}
+
+	return errMessages, string(bodyBytes)
+}
+
+//summarizeStreamed renders the Key/ResponseValue pair of each top-level config (keys nested in
+//AllOf/AnyOf are not included),
+//for attemptResult.respBody diagnostics - the whole point of streaming is to never hold the full
+//body in memory, so this is deliberately not a reconstruction of the original response
+func summarizeStreamed(validated []ResponseConfig) string {
+	var summary strings.Builder
+	for i := range validated {
+		if validated[i].Key == "" {
+			continue
+		}
+		fmt.Fprintf(&summary, "%s=%q ", validated[i].Key, validated[i].ResponseValue)
+	}
+
+	return summary.String()
+}
+
+//buildRequest creates the *http.Request for a requestConfig, including form/json bodies and
+//headers, bound to ctx so an expired/cancelled ctx aborts the in-flight request
+func buildRequest(ctx context.Context, requestConfig RequestConfig) (*http.Request, error) {
 	var request *http.Request
 	var reqErr error
 
 	if len(requestConfig.FormParams) == 0 {
 		//formParams create a request
-		request, reqErr = http.NewRequest(requestConfig.RequestType,
+		request, reqErr = http.NewRequestWithContext(ctx, requestConfig.RequestType,
 			requestConfig.Url,
 			nil)
 
@@ -201,20 +699,9 @@ func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 
 			jsonBody, jsonErr := GetJsonParamsBody(requestConfig.FormParams)
 			if jsonErr != nil {
-				//Not able to create Request object.Add Error to Database
-				go database.AddErrorInfo(database.ErrorInfo{
-					Id:           requestConfig.Id,
-					Url:          requestConfig.Url,
-					RequestType:  requestConfig.RequestType,
-					ResponseCode: 0,
-					ResponseBody: "",
-					Reason:       database.ErrCreateRequest,
-					OtherInfo:    jsonErr.Error(),
-				})
-
-				return jsonErr
+				return nil, jsonErr
 			}
-			request, reqErr = http.NewRequest(requestConfig.RequestType,
+			request, reqErr = http.NewRequestWithContext(ctx, requestConfig.RequestType,
 				requestConfig.Url,
 				jsonBody)
 
@@ -222,33 +709,24 @@ func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 			//create a request using formParams
 			formParams := GetUrlValues(requestConfig.FormParams)
 
-			request, reqErr = http.NewRequest(requestConfig.RequestType,
+			request, reqErr = http.NewRequestWithContext(ctx, requestConfig.RequestType,
 				requestConfig.Url,
 				bytes.NewBufferString(formParams.Encode()))
 
-			request.Header.Add(ContentLength, strconv.Itoa(len(formParams.Encode())))
+			if reqErr == nil {
+				request.Header.Add(ContentLength, strconv.Itoa(len(formParams.Encode())))
 
-			if requestConfig.Headers[ContentType] != "" {
-				//Add content type to header if user doesnt mention it config file
-				//Default content type application/x-www-form-urlencoded
-				request.Header.Add(ContentType, FormContentType)
+				if requestConfig.Headers[ContentType] != "" {
+					//Add content type to header if user doesnt mention it config file
+					//Default content type application/x-www-form-urlencoded
+					request.Header.Add(ContentType, FormContentType)
+				}
 			}
 		}
 	}
 
 	if reqErr != nil {
-		//Not able to create Request object.Add Error to Database
-		go database.AddErrorInfo(database.ErrorInfo{
-			Id:           requestConfig.Id,
-			Url:          requestConfig.Url,
-			RequestType:  requestConfig.RequestType,
-			ResponseCode: 0,
-			ResponseBody: "",
-			Reason:       database.ErrCreateRequest,
-			OtherInfo:    reqErr.Error(),
-		})
-
-		return reqErr
+		return nil, reqErr
 	}
 
 	//add url parameters to query if present
@@ -260,105 +738,33 @@ func PerformRequest(requestConfig RequestConfig, throttle chan int) error {
 	//Add headers to the request
 	AddHeaders(request, requestConfig.Headers)
 
-	//TODO: put timeout ?
-	/*
-		timeout := 10 * requestConfig.ResponseTime
-
-		client := &http.Client{
-			Timeout: timeout,
-		}
-	*/
-
-	client := &http.Client{}
-	start := time.Now()
-
-	getResponse, respErr := client.Do(request)
-
-	respBody := convertResponseToString(getResponse)
-
-	if respErr != nil {
-		//Request failed . Add error info to database
-		var statusCode int
-		if getResponse == nil {
-			statusCode = 0
-		} else {
-			statusCode = getResponse.StatusCode
-		}
-		go database.AddErrorInfo(database.ErrorInfo{
-			Id:           requestConfig.Id,
-			Url:          requestConfig.Url,
-			RequestType:  requestConfig.RequestType,
-			ResponseCode: statusCode,
-			ResponseBody: respBody,
-			Reason:       database.ErrDoRequest,
-			OtherInfo:    respErr.Error(),
-		})
-		return respErr
-	}
-
-	defer getResponse.Body.Close()
+	return request, nil
+}
 
-	if getResponse.StatusCode != requestConfig.ResponseCode {
-		//Response code is not the expected one .Add Error to database
-		go database.AddErrorInfo(database.ErrorInfo{
-			Id:           requestConfig.Id,
-			Url:          requestConfig.Url,
-			RequestType:  requestConfig.RequestType,
-			ResponseCode: getResponse.StatusCode,
-			ResponseBody: respBody,
-			Reason:       errResposeCode(getResponse.StatusCode, requestConfig.ResponseCode),
-			OtherInfo:    "",
-		})
-		return errResposeCode(getResponse.StatusCode, requestConfig.ResponseCode)
+//captureResponseBody reads resp.Body per requestConfig.BodyCapture and returns it as bytes.
+//CaptureNever discards the body outright (ResponseBody validators won't have anything to match
+//against). CaptureAlways and CaptureOnError both read up to MaxBytes to validate against;
+//anything beyond that is drained and discarded so the connection can still be reused, but never
+//held in memory. The two modes read the same here; the only place they differ is
+//validateResponseBody, which streams validation only under CaptureAlways. In either mode the
+//captured body is stored on an attemptResult only when that attempt returns an error; on
+//success it is dropped once validated.
+func captureResponseBody(resp *http.Response, capture BodyCaptureConfig) []byte {
+	if resp == nil {
+		return nil
 	}
+	defer io.Copy(io.Discard, resp.Body)
 
-	//Validate Response Body
-	for body := range requestConfig.ResponseBody {
-		respErr := ValidateResponse(respBody, requestConfig.ResponseBody[body])
-		fmt.Println("Errors found")
-		fmt.Println(respErr.ErrorMessage)
-		if len(respErr.ErrorMessage) != 0 {
-			var respMessage strings.Builder
-			for i := range respErr.ErrorMessage {
-				respMessage.WriteString("\n")
-				respMessage.WriteString(respErr.ErrorMessage[i])
-			}
-			notify.SendErrorNotification(notify.ErrorNotification{requestConfig.Url,
-				requestConfig.RequestType,
-				respBody,
-				"Response Body Validation",
-				respMessage.String()})
-		}
+	if capture.CaptureOn == CaptureNever {
+		return nil
 	}
 
-	elapsed := time.Since(start)
-
-	//Request succesfull . Add infomartion to Database
-	go database.AddRequestInfo(database.RequestInfo{
-		Id:                   requestConfig.Id,
-		Url:                  requestConfig.Url,
-		RequestType:          requestConfig.RequestType,
-		ResponseCode:         getResponse.StatusCode,
-		ResponseTime:         elapsed.Nanoseconds() / 1000000,
-		ExpectedResponseTime: requestConfig.ResponseTime,
-	})
-
-	return nil
-}
-
-//convert response body to string
-func convertResponseToString(resp *http.Response) string {
-	if resp == nil {
-		return " "
-	}
 	buf := new(bytes.Buffer)
-	_, bufErr := buf.ReadFrom(resp.Body)
-
-	if bufErr != nil {
-		return " "
+	if _, err := buf.ReadFrom(io.LimitReader(resp.Body, capture.MaxBytes)); err != nil {
+		return nil
 	}
 
-	return buf.String()
+	return buf.Bytes()
 }
 
 //Add header values from map to request
@@ -402,41 +808,21 @@ func errResposeCode(status int, expectedStatus int) error {
 	return errors.New(fmt.Sprintf("Got Response code %v. Expected Response Code %v ", status, expectedStatus))
 }
 
-func ValidateResponse(respBody string, responseConfig ResponseConfig) ResponseConfig {
-
-	resp := gjson.Get(respBody, responseConfig.Key).String()
-	responseConfig.ResponseValue = resp
-
-	//Default Valid status to true
-	responseConfig.Valid = true
-
-	var errMessage []string
-
-	//Validate whether the response is empty
-	if responseConfig.Empty == true && resp != "" {
-		responseConfig.Valid = false
-		errMessage = append(errMessage, "Response is not empty")
-	} else if responseConfig.Empty == false && resp == "" {
-		responseConfig.Valid = false
-		errMessage = append(errMessage, "Response is empty")
+//responseTimeBudgetRatio returns elapsed (in ms) divided by the configured response time budget
+func responseTimeBudgetRatio(elapsed time.Duration, requestConfig RequestConfig) float64 {
+	if requestConfig.ResponseTime == 0 {
+		return 0
 	}
 
-	//Validate character count greater than
-	if responseConfig.CharCountGreaterThan != 0 && int64(len(resp)) > responseConfig.CharCountGreaterThan {
-		responseConfig.Valid = false
-		errMessage = append(errMessage, "Character count is too small")
-	}
+	return float64(elapsed.Nanoseconds()/1000000) / float64(requestConfig.ResponseTime)
+}
 
-	fmt.Println(responseConfig)
-	if _, err := strconv.Atoi(resp); err != nil && responseConfig.Int == true {
-		responseConfig.Valid = false
-		errMessage = append(errMessage, "Response Value is not Integer")
-	} else if _, err := strconv.Atoi(resp); err == nil && responseConfig.Int == false {
-		responseConfig.Valid = false
-		errMessage = append(errMessage, "Response Value is an Integer")
+//recordSample feeds the alerting evaluator, if one was configured via RequestsInit
+func recordSample(requestId int, outcome string, elapsed time.Duration) {
+	if alertEvaluator == nil {
+		return
 	}
-	responseConfig.ErrorMessage = errMessage
-
-	return responseConfig
 
+	alertEvaluator.Record(requestId, alerting.Sample{Time: time.Now(), Outcome: outcome, Latency: elapsed})
 }
+
@@ -0,0 +1,48 @@
+package requests
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialInterval: 100 * time.Millisecond,
+		Multiplier:      2,
+		MaxInterval:     time.Second,
+	}
+
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second}, // capped by MaxInterval
+		{10, time.Second},
+	}
+
+	for _, c := range cases {
+		if got := policy.backoff(c.attempt); got != c.want {
+			t.Errorf("backoff(%d) = %v, want %v", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	policy := RetryPolicy{RetryOn: []string{RetryOnTransport, "503"}}
+
+	if !policy.retryable(RetryOnTransport, 0) {
+		t.Error("expected transport class to be retryable")
+	}
+
+	if !policy.retryable("", 503) {
+		t.Error("expected status code 503 to be retryable")
+	}
+
+	if policy.retryable(RetryOnCodeMismatch, 500) {
+		t.Error("expected code_mismatch/500 to not be retryable, neither is listed in RetryOn")
+	}
+}
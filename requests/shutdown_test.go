@@ -0,0 +1,31 @@
+package requests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+//TestShutdownIsIdempotent reproduces calling Shutdown twice (eg. a caller with both a deferred
+//and an explicit Shutdown, or a retry after a timed-out one) - it used to panic with
+//"close of closed channel" on the second call.
+func TestShutdownIsIdempotent(t *testing.T) {
+	shutdownOnce = sync.Once{}
+	monitorCtx, monitorCancel = context.WithCancel(context.Background())
+	RequestsList = []RequestConfig{{CheckEvery: time.Hour}}
+	requestChannel = make(chan RequestConfig, len(RequestsList))
+
+	StartMonitoring()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("first Shutdown: %v", err)
+	}
+
+	if err := Shutdown(ctx); err != nil {
+		t.Fatalf("second Shutdown: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package requests
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateResponseStreamMatchesBufferedValidation(t *testing.T) {
+	configs := []ResponseConfig{
+		{Key: "status", Equals: "ok"},
+		{Key: "count", Int: true, GreaterThan: floatPtr(0)},
+	}
+
+	body := `{"status":"ok","count":3,"ignored":{"big":"payload"}}`
+
+	validated, err := ValidateResponseStream(strings.NewReader(body), configs)
+	if err != nil {
+		t.Fatalf("ValidateResponseStream: %v", err)
+	}
+
+	for i := range validated {
+		if !validated[i].Valid {
+			t.Errorf("config %d: expected valid, got errors: %v", i, validated[i].ErrorMessage)
+		}
+	}
+
+	buffered := []ResponseConfig{
+		ValidateResponse(body, configs[0]),
+		ValidateResponse(body, configs[1]),
+	}
+
+	for i := range validated {
+		if validated[i].Valid != buffered[i].Valid || validated[i].ResponseValue != buffered[i].ResponseValue {
+			t.Errorf("config %d: streamed result %+v diverged from buffered result %+v", i, validated[i], buffered[i])
+		}
+	}
+}
+
+func TestValidateResponseStreamStopsOnceEveryKeyIsFound(t *testing.T) {
+	configs := []ResponseConfig{{Key: "status", Equals: "ok"}}
+
+	// everything after "status" is malformed, so this only succeeds if the scan stops reading
+	// once "status" satisfies every key being looked for
+	body := `{"status":"ok","tail":this is not valid JSON`
+
+	validated, err := ValidateResponseStream(strings.NewReader(body), configs)
+	if err != nil {
+		t.Fatalf("ValidateResponseStream: %v", err)
+	}
+
+	if !validated[0].Valid {
+		t.Errorf("expected status=ok to validate, got errors: %v", validated[0].ErrorMessage)
+	}
+}
+
+func TestValidateResponseStreamRejectsNestedKeys(t *testing.T) {
+	configs := []ResponseConfig{{Key: "data.status", Equals: "ok"}}
+
+	if canStreamValidate(configs) {
+		t.Fatal("expected a nested gjson key to not be streamable")
+	}
+
+	if _, err := ValidateResponseStream(strings.NewReader(`{}`), configs); err != errNotStreamable {
+		t.Errorf("expected errNotStreamable, got %v", err)
+	}
+}
+
+func TestValidateResponseStreamRejectsNonObjectBody(t *testing.T) {
+	configs := []ResponseConfig{{Key: "status", Equals: "ok"}}
+
+	if _, err := ValidateResponseStream(strings.NewReader(`["not", "an", "object"]`), configs); err == nil {
+		t.Error("expected an error for a top-level JSON array")
+	}
+}
+
+func TestValidateResponseStreamAllOf(t *testing.T) {
+	configs := []ResponseConfig{
+		{
+			AllOf: []ResponseConfig{
+				{Key: "status", Equals: "ok"},
+				{Key: "count", Int: true, GreaterThan: floatPtr(0)},
+			},
+		},
+	}
+
+	body := `{"status":"ok","count":1}`
+
+	validated, err := ValidateResponseStream(strings.NewReader(body), configs)
+	if err != nil {
+		t.Fatalf("ValidateResponseStream: %v", err)
+	}
+
+	if !validated[0].Valid {
+		t.Errorf("expected AllOf to pass, got errors: %v", validated[0].ErrorMessage)
+	}
+}
@@ -0,0 +1,317 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//compileResponseConfigs compiles MatchesRegex/JSONSchema for a ResponseConfig tree (including
+//nested AllOf/AnyOf) once at config load time, so ValidateResponse never pays compilation cost
+//per request
+func compileResponseConfigs(configs []ResponseConfig) error {
+	for i := range configs {
+		if err := configs[i].compile(); err != nil {
+			return err
+		}
+
+		if err := compileResponseConfigs(configs[i].AllOf); err != nil {
+			return err
+		}
+		if err := compileResponseConfigs(configs[i].AnyOf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (responseConfig *ResponseConfig) compile() error {
+	if responseConfig.CharCountGreaterThan != 0 {
+		fmt.Printf("[deprecated] responseBody[%s]: charCountGreaterThan is deprecated, use lengthBetween instead\n", responseConfig.Key)
+	}
+
+	if responseConfig.MatchesRegex != "" {
+		re, err := regexp.Compile(responseConfig.MatchesRegex)
+		if err != nil {
+			return fmt.Errorf("responseBody[%s]: invalid matchesRegex %q: %w", responseConfig.Key, responseConfig.MatchesRegex, err)
+		}
+		responseConfig.compiledRegex = re
+	}
+
+	if responseConfig.JSONSchema != "" {
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewReferenceLoader("file://" + responseConfig.JSONSchema))
+		if err != nil {
+			return fmt.Errorf("responseBody[%s]: invalid jsonSchema %q: %w", responseConfig.Key, responseConfig.JSONSchema, err)
+		}
+		responseConfig.compiledSchema = schema
+	}
+
+	return nil
+}
+
+//ValidateResponse checks a single ResponseConfig (and, via AllOf/AnyOf, any configs it composes)
+//against respBody and returns a copy with Valid/ErrorMessage/ResponseValue populated. Scalar
+//validator error messages include the gjson key and the actual value observed, to aid debugging;
+//jsonSchema errors carry the key and the schema library's description instead of the value.
+func ValidateResponse(respBody string, responseConfig ResponseConfig) ResponseConfig {
+	return ValidateResponseBytes([]byte(respBody), responseConfig)
+}
+
+//ValidateResponseBytes is identical to ValidateResponse but takes the response body as bytes, so
+//a BodyCaptureConfig.SkipStringCopy caller can validate straight off the bytes it captured
+//instead of paying for a []byte -> string copy first.
+func ValidateResponseBytes(respBody []byte, responseConfig ResponseConfig) ResponseConfig {
+	return validateWithLookup(responseConfig, func(key string) gjson.Result {
+		return gjson.GetBytes(respBody, key)
+	})
+}
+
+//validateWithLookup is the shared recursive core of ValidateResponseBytes and
+//ValidateResponseStream: everything here only ever asks lookup for one key's value at a time, so
+//the same validator logic runs whether that lookup is backed by a fully materialized []byte or a
+//token-at-a-time scan of the live response.
+func validateWithLookup(responseConfig ResponseConfig, lookup func(key string) gjson.Result) ResponseConfig {
+	result := lookup(responseConfig.Key)
+	responseConfig.ResponseValue = result.String()
+
+	var errMessages []string
+
+	if hasScalarValidators(responseConfig) {
+		errMessages = append(errMessages, validateScalar(result, responseConfig)...)
+	}
+
+	for i := range responseConfig.AllOf {
+		validated := validateWithLookup(responseConfig.AllOf[i], lookup)
+		errMessages = append(errMessages, validated.ErrorMessage...)
+	}
+
+	if len(responseConfig.AnyOf) > 0 {
+		var anyValid bool
+		var anyErrs []string
+
+		for i := range responseConfig.AnyOf {
+			validated := validateWithLookup(responseConfig.AnyOf[i], lookup)
+			if validated.Valid {
+				anyValid = true
+				break
+			}
+			anyErrs = append(anyErrs, validated.ErrorMessage...)
+		}
+
+		if !anyValid {
+			errMessages = append(errMessages, fmt.Sprintf("anyOf: none of %d alternatives matched: %s", len(responseConfig.AnyOf), strings.Join(anyErrs, "; ")))
+		}
+	}
+
+	responseConfig.Valid = len(errMessages) == 0
+	responseConfig.ErrorMessage = errMessages
+
+	return responseConfig
+}
+
+//errNotStreamable means a ResponseConfig tree can't be validated by ValidateResponseStream and
+//the caller should fall back to buffering the body instead
+var errNotStreamable = errors.New("requests: response config uses a nested gjson path (eg. \".\"/\"[]\"), streaming validation only supports flat top-level keys")
+
+//canStreamValidate reports whether ValidateResponseStream can handle configs, without touching
+//a response body - callers should check this up front and, if false, go straight to the buffered
+//ValidateResponse(Bytes) path instead of calling ValidateResponseStream at all. Once
+//ValidateResponseStream has started reading from a live response body there's no rewinding it,
+//so this check has to happen before anything is read, not as a fallback after a failed attempt.
+func canStreamValidate(configs []ResponseConfig) bool {
+	return collectStreamableKeys(configs, make(map[string]bool))
+}
+
+//ValidateResponseStream validates configs directly against body, a live (not yet fully read)
+//response body reader, without ever buffering the whole thing into memory: it walks body's
+//top-level JSON object one field at a time, keeping only the raw value of whichever keys configs
+//actually asks about and discarding the rest as it goes. This only works for flat, top-level
+//gjson keys (no "." or "[]" path syntax) since that's all a single token-by-token pass can locate
+//without look-ahead - configs using a nested path make this return errNotStreamable, and the
+//caller should fall back to ValidateResponseBytes against a buffered copy instead.
+func ValidateResponseStream(body io.Reader, configs []ResponseConfig) ([]ResponseConfig, error) {
+	keys := make(map[string]bool)
+	if !collectStreamableKeys(configs, keys) {
+		return nil, errNotStreamable
+	}
+
+	found, err := scanTopLevelKeys(body, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := func(key string) gjson.Result {
+		raw, ok := found[key]
+		if !ok {
+			return gjson.Result{}
+		}
+		return gjson.ParseBytes(raw)
+	}
+
+	validated := make([]ResponseConfig, len(configs))
+	for i := range configs {
+		validated[i] = validateWithLookup(configs[i], lookup)
+	}
+
+	return validated, nil
+}
+
+//collectStreamableKeys walks configs (including nested AllOf/AnyOf) gathering every Key into
+//keys, and reports false the moment it finds one that isn't a flat top-level key
+func collectStreamableKeys(configs []ResponseConfig, keys map[string]bool) bool {
+	for _, responseConfig := range configs {
+		if responseConfig.Key != "" {
+			if strings.ContainsAny(responseConfig.Key, ".[]#@") {
+				return false
+			}
+			keys[responseConfig.Key] = true
+		}
+
+		if !collectStreamableKeys(responseConfig.AllOf, keys) {
+			return false
+		}
+		if !collectStreamableKeys(responseConfig.AnyOf, keys) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//scanTopLevelKeys reads body as a single top-level JSON object and returns the raw value of
+//every field in keys, stopping as soon as all of them are found. Fields not in keys are decoded
+//one at a time into a throwaway RawMessage and dropped, so at most one unwanted field is ever
+//held in memory at once - body's remaining, unread bytes never are.
+func scanTopLevelKeys(body io.Reader, keys map[string]bool) (map[string]json.RawMessage, error) {
+	decoder := json.NewDecoder(body)
+
+	open, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("requests: streaming validation: %w", err)
+	}
+	if delim, ok := open.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("requests: streaming validation needs a top-level JSON object, got %v", open)
+	}
+
+	found := make(map[string]json.RawMessage, len(keys))
+	for decoder.More() && len(found) < len(keys) {
+		nameTok, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("requests: streaming validation: %w", err)
+		}
+
+		name, _ := nameTok.(string)
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("requests: streaming validation: %w", err)
+		}
+
+		if keys[name] {
+			found[name] = raw
+		}
+	}
+
+	return found, nil
+}
+
+//hasScalarValidators reports whether responseConfig has any of the non-composition validators
+//set, ie. anything that needs to be evaluated against gjson.Get(respBody, responseConfig.Key)
+func hasScalarValidators(responseConfig ResponseConfig) bool {
+	return responseConfig.Key != "" ||
+		responseConfig.Empty ||
+		responseConfig.Int ||
+		responseConfig.CharCountGreaterThan != 0 ||
+		responseConfig.Equals != "" ||
+		responseConfig.NotEquals != "" ||
+		responseConfig.MatchesRegex != "" ||
+		responseConfig.LessThan != nil ||
+		responseConfig.GreaterThan != nil ||
+		len(responseConfig.OneOf) != 0 ||
+		responseConfig.LengthBetween != [2]int{} ||
+		responseConfig.JSONSchema != ""
+}
+
+//validateScalar runs every non-composition validator configured on responseConfig against result
+func validateScalar(result gjson.Result, responseConfig ResponseConfig) []string {
+	resp := result.String()
+	var errMessages []string
+
+	if responseConfig.Empty && resp != "" {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected empty response, got %q", responseConfig.Key, resp))
+	} else if !responseConfig.Empty && resp == "" {
+		errMessages = append(errMessages, fmt.Sprintf("%s: response is empty", responseConfig.Key))
+	}
+
+	if responseConfig.CharCountGreaterThan != 0 && int64(len(resp)) <= responseConfig.CharCountGreaterThan {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected char count greater than %d, got %d (value %q)", responseConfig.Key, responseConfig.CharCountGreaterThan, len(resp), resp))
+	}
+
+	if _, err := strconv.Atoi(resp); err != nil && responseConfig.Int {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected an integer, got %q", responseConfig.Key, resp))
+	} else if err == nil && !responseConfig.Int {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected a non-integer, got %q", responseConfig.Key, resp))
+	}
+
+	if responseConfig.Equals != "" && resp != responseConfig.Equals {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected %q to equal %q", responseConfig.Key, resp, responseConfig.Equals))
+	}
+
+	if responseConfig.NotEquals != "" && resp == responseConfig.NotEquals {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected %q to not equal %q", responseConfig.Key, resp, responseConfig.NotEquals))
+	}
+
+	if responseConfig.compiledRegex != nil && !responseConfig.compiledRegex.MatchString(resp) {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected %q to match regex %q", responseConfig.Key, resp, responseConfig.MatchesRegex))
+	}
+
+	if responseConfig.LessThan != nil && !(result.Float() < *responseConfig.LessThan) {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected %v to be less than %v", responseConfig.Key, resp, *responseConfig.LessThan))
+	}
+
+	if responseConfig.GreaterThan != nil && !(result.Float() > *responseConfig.GreaterThan) {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected %v to be greater than %v", responseConfig.Key, resp, *responseConfig.GreaterThan))
+	}
+
+	if len(responseConfig.OneOf) != 0 && !contains(responseConfig.OneOf, resp) {
+		errMessages = append(errMessages, fmt.Sprintf("%s: expected %q to be one of %v", responseConfig.Key, resp, responseConfig.OneOf))
+	}
+
+	if responseConfig.LengthBetween != [2]int{} {
+		min, max := responseConfig.LengthBetween[0], responseConfig.LengthBetween[1]
+		if len(resp) < min || len(resp) > max {
+			errMessages = append(errMessages, fmt.Sprintf("%s: expected length of %q (%d) to be between %d and %d", responseConfig.Key, resp, len(resp), min, max))
+		}
+	}
+
+	if responseConfig.compiledSchema != nil {
+		jsonResult, err := responseConfig.compiledSchema.Validate(gojsonschema.NewStringLoader(result.Raw))
+		if err != nil {
+			errMessages = append(errMessages, fmt.Sprintf("%s: jsonSchema validation failed to run: %s", responseConfig.Key, err.Error()))
+		} else if !jsonResult.Valid() {
+			for _, resultErr := range jsonResult.Errors() {
+				errMessages = append(errMessages, fmt.Sprintf("%s: jsonSchema: %s", responseConfig.Key, resultErr.String()))
+			}
+		}
+	}
+
+	return errMessages
+}
+
+func contains(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package requests
+
+import "testing"
+
+func TestValidateResponseScalarValidators(t *testing.T) {
+	config := ResponseConfig{Key: "name", Equals: "ok"}
+	if err := config.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	valid := ValidateResponse(`{"name":"ok"}`, config)
+	if !valid.Valid {
+		t.Errorf("expected Equals to match, got errors: %v", valid.ErrorMessage)
+	}
+
+	invalid := ValidateResponse(`{"name":"not-ok"}`, config)
+	if invalid.Valid {
+		t.Error("expected Equals to fail for a mismatched value")
+	}
+}
+
+func TestValidateResponseMatchesRegex(t *testing.T) {
+	config := ResponseConfig{Key: "status", MatchesRegex: `^[a-z]+$`}
+	if err := config.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	if !ValidateResponse(`{"status":"ok"}`, config).Valid {
+		t.Error("expected matchesRegex to match a lowercase status")
+	}
+
+	if ValidateResponse(`{"status":"NOT-OK"}`, config).Valid {
+		t.Error("expected matchesRegex to reject a non-matching status")
+	}
+}
+
+func TestValidateResponseCharCountGreaterThan(t *testing.T) {
+	config := ResponseConfig{Key: "message", CharCountGreaterThan: 5}
+	if err := config.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	if !ValidateResponse(`{"message":"hello world"}`, config).Valid {
+		t.Error("expected a value longer than the threshold to pass")
+	}
+
+	invalid := ValidateResponse(`{"message":"hi"}`, config)
+	if invalid.Valid {
+		t.Error("expected a value no longer than the threshold to fail")
+	}
+}
+
+func TestValidateResponseAllOf(t *testing.T) {
+	config := ResponseConfig{
+		AllOf: []ResponseConfig{
+			{Key: "status", Equals: "ok"},
+			{Key: "count", Int: true, GreaterThan: floatPtr(0)},
+		},
+	}
+	if err := compileResponseConfigs(config.AllOf); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	body := `{"status":"ok","count":1}`
+	if !ValidateResponse(body, config).Valid {
+		t.Error("expected AllOf to pass when every nested config matches")
+	}
+
+	if ValidateResponse(`{"status":"fail","count":1}`, config).Valid {
+		t.Error("expected AllOf to fail when one nested config doesn't match")
+	}
+}
+
+func TestValidateResponseAnyOf(t *testing.T) {
+	config := ResponseConfig{
+		AnyOf: []ResponseConfig{
+			{Key: "status", Equals: "ok"},
+			{Key: "status", Equals: "degraded"},
+		},
+	}
+	if err := compileResponseConfigs(config.AnyOf); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	if !ValidateResponse(`{"status":"degraded"}`, config).Valid {
+		t.Error("expected AnyOf to pass when one alternative matches")
+	}
+
+	if ValidateResponse(`{"status":"down"}`, config).Valid {
+		t.Error("expected AnyOf to fail when no alternative matches")
+	}
+}
+
+func floatPtr(f float64) *float64 { return &f }